@@ -0,0 +1,84 @@
+package sps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// bandcampBlobRx matches the JSON blob Bandcamp embeds in its tag pages
+// via a data-blob attribute, which is the only place the tag's matching
+// releases show up without a dedicated search API.
+var bandcampBlobRx = regexp.MustCompile(`data-blob="([^"]+)"`)
+
+type bandcampTagPageBlob struct {
+	DigDeeper struct {
+		Items []struct {
+			BandName    string `json:"band_name"`
+			Name        string `json:"name"`
+			ReleaseDate string `json:"release_date"`
+		} `json:"items"`
+	} `json:"dig_deeper"`
+}
+
+// BandcampSource is an AlbumSource that scrapes Bandcamp's tag pages,
+// following the same approach as the external undertideco/bandcamp search
+// client.
+type BandcampSource struct{}
+
+func NewBandcampSource() *BandcampSource {
+	return &BandcampSource{}
+}
+
+func (b *BandcampSource) Search(ctx context.Context, criteria SearchCriteria) <-chan Album {
+	out := make(chan Album)
+	go func() {
+		defer close(out)
+		if err := b.search(ctx, criteria, out); err != nil {
+			fmt.Println("bandcamp source error:", err)
+		}
+	}()
+	return out
+}
+
+func (b *BandcampSource) search(ctx context.Context, criteria SearchCriteria, out chan<- Album) error {
+	tagUrl := fmt.Sprintf("https://bandcamp.com/tag/%s?sort_field=date", url.PathEscape(strings.ToLower(criteria.Style)))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", tagUrl, nil)
+	if err != nil {
+		return err
+	}
+	// doSimpleRequest, not doRequest: a transport error here must stay an
+	// error the fan-in can log and move past, not take the whole sync down.
+	body, err := doSimpleRequest(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+
+	m := bandcampBlobRx.FindSubmatch(body)
+	if m == nil {
+		return fmt.Errorf("bandcamp: could not find results blob for tag %q", criteria.Style)
+	}
+	var blob bandcampTagPageBlob
+	if err := json.Unmarshal([]byte(html.UnescapeString(string(m[1]))), &blob); err != nil {
+		return err
+	}
+
+	for _, item := range blob.DigDeeper.Items {
+		if criteria.Year != "" && !strings.HasPrefix(item.ReleaseDate, criteria.Year) {
+			continue
+		}
+		album := Album{Title: fmt.Sprintf("%s - %s", item.BandName, item.Name), Source: "bandcamp"}
+		select {
+		case out <- album:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
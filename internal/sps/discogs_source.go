@@ -0,0 +1,274 @@
+package sps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/mcartmell/spotify-playlist-sync/internal/store"
+)
+
+const discogsSearchURL = "https://api.discogs.com/database/search"
+
+// discogsWorkerCount is how many goroutines concurrently resolve master
+// release years and filter candidates while the main loop keeps paging.
+const discogsWorkerCount = 4
+
+type DiscogsSearchResult struct {
+	Title     string `json:"title"`
+	Community struct {
+		Have int `json:"have"`
+	} `json:"community"`
+	Format    []string `json:"format"`
+	Year      string   `json:"year"`
+	Style     []string `json:"style"`
+	Thumb     string   `json:"thumb"`
+	Uri       string   `json:"uri"`
+	Artist    []string `json:"artist"`
+	MasterURL string   `json:"master_url"`
+}
+
+type DiscogsSearchResponse struct {
+	Results    []DiscogsSearchResult `json:"results"`
+	Pagination struct {
+		Items   int `json:"items"`
+		PerPage int `json:"per_page"`
+		Page    int `json:"page"`
+		Pages   int `json:"pages"`
+		Urls    struct {
+			Last string `json:"last"`
+			Next string `json:"next"`
+		} `json:"urls"`
+	} `json:"pagination"`
+}
+
+type DiscogsMasterResponse struct {
+	Year int `json:"year"`
+}
+
+// DiscogsSource is an AlbumSource backed by the Discogs database search,
+// walking pagination and filtering out reissues/remasters and excluded
+// styles along the way. Requests share a token-bucket limiter tuned to
+// Discogs' documented 60 req/min authenticated rate limit, and back off on
+// 429s using the Retry-After header.
+type DiscogsSource struct {
+	Token          string
+	ExcludedStyles []string
+	Verbose        bool
+
+	// MasterYearCache, if set, is consulted before resolving a master
+	// release's year over the network, and updated after a fresh lookup
+	// so repeat runs against the same master don't re-fetch it.
+	MasterYearCache *store.Store
+
+	limiter *rate.Limiter
+}
+
+func NewDiscogsSource(token string, excludedStyles []string, verbose bool) *DiscogsSource {
+	return &DiscogsSource{
+		Token:          token,
+		ExcludedStyles: excludedStyles,
+		Verbose:        verbose,
+		limiter:        rate.NewLimiter(rate.Limit(1), 2),
+	}
+}
+
+func (d *DiscogsSource) v(f string, args ...interface{}) {
+	if !d.Verbose {
+		return
+	}
+	fmt.Printf(f, args...)
+}
+
+func (d *DiscogsSource) Search(ctx context.Context, criteria SearchCriteria) <-chan Album {
+	out := make(chan Album)
+	candidates := make(chan DiscogsSearchResult)
+
+	go func() {
+		defer close(candidates)
+		if err := d.fetchPages(ctx, criteria, candidates); err != nil {
+			fmt.Println("discogs source error:", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	albumsSeen := map[string]bool{}
+	for i := 0; i < discogsWorkerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.worker(ctx, criteria, candidates, out, &mu, albumsSeen)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// fetchPages walks Discogs' search pagination, sending every raw result to
+// candidates. It only paginates - filtering and master-year lookups happen
+// concurrently in the worker pool so a slow master-year fetch never stalls
+// the next page request.
+func (d *DiscogsSource) fetchPages(ctx context.Context, criteria SearchCriteria, candidates chan<- DiscogsSearchResult) error {
+	// search params
+	albumType := "release"
+	format := "Album"
+	searchUrl := fmt.Sprintf("%s?type=%s&style=%s&format=%s&year=%s&token=%s&per_page=100", discogsSearchURL, albumType, criteria.Style, format, criteria.Year, d.Token)
+	for {
+		fmt.Println("fetching", searchUrl)
+		req, err := http.NewRequestWithContext(ctx, "GET", searchUrl, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Add("User-Agent", "SpotifyPlaylistSync/0.1")
+
+		body, err := doRateLimitedRequest(ctx, req, http.StatusOK, d.limiter, "X-Discogs-Ratelimit-Remaining")
+		if err != nil {
+			return err
+		}
+		var discogsResponse DiscogsSearchResponse
+		if err := json.Unmarshal(body, &discogsResponse); err != nil {
+			return err
+		}
+		for _, result := range discogsResponse.Results {
+			select {
+			case candidates <- result:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		searchUrl = discogsResponse.Pagination.Urls.Next
+		if searchUrl == "" {
+			break
+		}
+	}
+	return nil
+}
+
+var discogsDisambiguationRx = regexp.MustCompile(`\s\(\d+\)`)
+
+// worker drains candidates, filters each result, resolves its master
+// release year if it has one, and sends the survivors to out.
+func (d *DiscogsSource) worker(ctx context.Context, criteria SearchCriteria, candidates <-chan DiscogsSearchResult, out chan<- Album, mu *sync.Mutex, albumsSeen map[string]bool) {
+	for result := range candidates {
+		if !d.matchesStyle(result, criteria.Style) {
+			continue
+		}
+		if d.excludedByStyle(result) {
+			continue
+		}
+		if result.Community.Have < 10 {
+			d.v("skipping %s because it has less than 10 copies\n", result.Title)
+			continue
+		}
+		if isReissueOrRemaster(result.Format) {
+			d.v("skipping %s because it is a reissue or remaster\n", result.Title)
+			continue
+		}
+		if result.MasterURL != "" {
+			masterYear, err := d.getMasterReleaseYear(ctx, result.MasterURL)
+			if err != nil {
+				fmt.Println("discogs source error:", err)
+				continue
+			}
+			if masterYear != criteria.Year {
+				d.v("skipping %s because master release year %s does not match search year %s\n", result.Title, masterYear, criteria.Year)
+				continue
+			}
+		}
+
+		title := discogsDisambiguationRx.ReplaceAllString(result.Title, "")
+
+		mu.Lock()
+		dup := albumsSeen[title]
+		albumsSeen[title] = true
+		mu.Unlock()
+		if dup {
+			continue
+		}
+
+		select {
+		case out <- Album{Title: title, Source: "discogs", MasterURL: result.MasterURL}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *DiscogsSource) matchesStyle(result DiscogsSearchResult, style string) bool {
+	if len(result.Style) == 1 && !strings.HasSuffix(result.Style[0], style) {
+		d.v("skipping %s - %s because it doesn't match style %s\n", result.Artist[0], result.Title, style)
+		return false
+	}
+	if len(result.Style) > 1 && (!strings.HasSuffix(result.Style[0], style) && !strings.HasSuffix(result.Style[1], style)) {
+		d.v("skipping %s because it doesn't match style %s\n", result.Title, style)
+		return false
+	}
+	return true
+}
+
+func (d *DiscogsSource) excludedByStyle(result DiscogsSearchResult) bool {
+	for _, style := range result.Style {
+		for _, exc := range d.ExcludedStyles {
+			if strings.Contains(style, exc) {
+				d.v("skipping %s because it contains excluded style %s\n", result.Title, exc)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *DiscogsSource) getMasterReleaseYear(ctx context.Context, masterURL string) (string, error) {
+	if d.MasterYearCache != nil {
+		if year, ok, err := d.MasterYearCache.MasterYear(masterURL); err != nil {
+			fmt.Println("master year cache lookup failed:", err)
+		} else if ok {
+			return year, nil
+		}
+	}
+
+	masterURLWithToken := fmt.Sprintf("%s?token=%s", masterURL, d.Token)
+	req, err := http.NewRequestWithContext(ctx, "GET", masterURLWithToken, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("User-Agent", "SpotifyPlaylistSync/0.1")
+
+	body, err := doRateLimitedRequest(ctx, req, http.StatusOK, d.limiter, "X-Discogs-Ratelimit-Remaining")
+	if err != nil {
+		return "", err
+	}
+	var masterResponse DiscogsMasterResponse
+	if err := json.Unmarshal(body, &masterResponse); err != nil {
+		return "", err
+	}
+	year := strconv.Itoa(masterResponse.Year)
+
+	if d.MasterYearCache != nil {
+		if err := d.MasterYearCache.CacheMasterYear(masterURL, year); err != nil {
+			fmt.Println("failed to cache master year:", err)
+		}
+	}
+	return year, nil
+}
+
+func isReissueOrRemaster(format []string) bool {
+	for _, f := range format {
+		if f == "Reissue" || f == "Remastered" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package sps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const listenBrainzStatsURL = "https://api.listenbrainz.org/1/stats/user"
+
+type listenBrainzReleasesResponse struct {
+	Payload struct {
+		Releases []struct {
+			ArtistName  string `json:"artist_name"`
+			ReleaseName string `json:"release_name"`
+		} `json:"releases"`
+	} `json:"payload"`
+}
+
+// ListenBrainzSource is an AlbumSource backed by a single ListenBrainz
+// user's top releases, for building playlists out of what that user has
+// actually been listening to. ListenBrainz's stats API doesn't expose a
+// release year, so criteria.Year is ignored here.
+type ListenBrainzSource struct {
+	Username string
+}
+
+func NewListenBrainzSource(username string) *ListenBrainzSource {
+	return &ListenBrainzSource{Username: username}
+}
+
+func (l *ListenBrainzSource) Search(ctx context.Context, criteria SearchCriteria) <-chan Album {
+	out := make(chan Album)
+	go func() {
+		defer close(out)
+		if err := l.search(ctx, out); err != nil {
+			fmt.Println("listenbrainz source error:", err)
+		}
+	}()
+	return out
+}
+
+func (l *ListenBrainzSource) search(ctx context.Context, out chan<- Album) error {
+	statsUrl := fmt.Sprintf("%s/%s/releases?range=all_time&count=100", listenBrainzStatsURL, url.PathEscape(l.Username))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", statsUrl, nil)
+	if err != nil {
+		return err
+	}
+	// doSimpleRequest, not doRequest: a transport error here must stay an
+	// error the fan-in can log and move past, not take the whole sync down.
+	body, err := doSimpleRequest(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var resp listenBrainzReleasesResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	for _, release := range resp.Payload.Releases {
+		album := Album{Title: fmt.Sprintf("%s - %s", release.ArtistName, release.ReleaseName), Source: "listenbrainz"}
+		select {
+		case out <- album:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
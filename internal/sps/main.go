@@ -3,6 +3,7 @@ package sps
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -12,21 +13,22 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/adrg/strutil"
-	"github.com/adrg/strutil/metrics"
 	"github.com/joho/godotenv"
+	"golang.org/x/time/rate"
+
+	"github.com/mcartmell/spotify-playlist-sync/internal/store"
 )
 
 func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	// .env is optional (e.g. in CI or when config comes purely from the
+	// environment); only a malformed file is worth failing on.
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		log.Fatal("Error loading .env file: ", err)
 	}
 }
 
@@ -36,11 +38,16 @@ var (
 
 const (
 	spotifyAuthorizeURL = "https://accounts.spotify.com/authorize"
-	discogsSearchURL    = "https://api.discogs.com/database/search"
 )
 
+// Album is a candidate found by an AlbumSource (or built directly from a
+// bands file). Source and MasterURL are best-effort provenance used to
+// key the store's seen-album cache; sources that don't have an
+// equivalent just leave them blank.
 type Album struct {
-	Title string
+	Title     string
+	Source    string
+	MasterURL string
 }
 
 type SpotifyAlbumResponse struct {
@@ -74,61 +81,101 @@ type SpotifySearchResponse struct {
 	}
 }
 
-type DiscogsSearchResult struct {
-	Title     string `json:"title"`
-	Community struct {
-		Have int `json:"have"`
-	} `json:"community"`
-	Format    []string `json:"format"`
-	Year      string   `json:"year"`
-	Style     []string `json:"style"`
-	Thumb     string   `json:"thumb"`
-	Uri       string   `json:"uri"`
-	Artist    []string `json:"artist"`
-	MasterURL string   `json:"master_url"`
+type SpotifyPlaylistSync struct {
+	tokens         *TokenSource
+	excludedStyles []string
+	verbose        bool
+	matcher        *Matcher
+	limiter        *rate.Limiter
+
+	bandcamp        *BandcampClient
+	bandcampMatches []BandcampMatch
+	stats           runStats
+
+	// store persists seen albums, playlist tracks and cached master-year
+	// lookups across runs. It's nil-safe: every method that uses it
+	// checks for nil first, so a SpotifyPlaylistSync built without one
+	// (e.g. in tests) just runs without incremental skipping, priming or
+	// undo support.
+	store  *store.Store
+	dryRun bool
+	runID  string
 }
 
-type DiscogsSearchResponse struct {
-	Results    []DiscogsSearchResult `json:"results"`
-	Pagination struct {
-		Items   int `json:"items"`
-		PerPage int `json:"per_page"`
-		Page    int `json:"page"`
-		Pages   int `json:"pages"`
-		Urls    struct {
-			Last string `json:"last"`
-			Next string `json:"next"`
-		} `json:"urls"`
-	} `json:"pagination"`
+// BandcampMatch is a verified Bandcamp mirror link found for an album
+// that couldn't be matched on Spotify.
+type BandcampMatch struct {
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	URL    string `json:"url"`
 }
 
-type DiscogsMasterResponse struct {
-	Year int `json:"year"`
+// runStats totals up what happened to the albums considered during a run,
+// printed as a summary once the run finishes.
+type runStats struct {
+	Added         int
+	NoMatch       int
+	BandcampFound int
 }
 
-type SpotifyPlaylistSync struct {
-	accessToken    string
-	excludedStyles []string
-	verbose        bool
+func NewSpotifyPlaylistSync(tokens *TokenSource) *SpotifyPlaylistSync {
+	return &SpotifyPlaylistSync{
+		tokens:  tokens,
+		matcher: DefaultMatcher(),
+		limiter: rate.NewLimiter(rate.Limit(5), 10),
+	}
 }
 
-func NewSpotifyPlaylistSync() *SpotifyPlaylistSync {
-	return &SpotifyPlaylistSync{
-		accessToken: getSpotifyAccessToken(),
+// token returns the current access token, refreshing it first if it's
+// close to expiring. A transient refresh failure (e.g. a network blip
+// partway through a long cron run) is retried a few times with a short
+// pause before giving up, rather than taking the whole run down on the
+// first hiccup.
+func (s *SpotifyPlaylistSync) token() string {
+	var accessToken string
+	var err error
+	for i := 0; i < 3; i++ {
+		accessToken, err = s.tokens.Token()
+		if err == nil {
+			return accessToken
+		}
+		fmt.Println("token refresh failed, retrying:", err)
+		time.Sleep(2 * time.Second)
 	}
+	log.Fatal(err)
+	return ""
 }
 
+// getTracksInPlaylist fetches every track currently in playlistID. If s
+// has a store, it's primed first with whatever was recorded for this
+// playlist on a previous (possibly crashed mid-pagination) run, and each
+// page fetched here is persisted as it lands rather than only once the
+// whole fetch succeeds - so a crash between pages still leaves the DB
+// primed with everything seen so far.
 func (s *SpotifyPlaylistSync) getTracksInPlaylist(playlistID string) ([]string, error) {
+	seen := map[string]bool{}
 	var tracks []string
+
+	if s.store != nil {
+		primed, err := s.store.SeenTracks(playlistID)
+		if err != nil {
+			return nil, err
+		}
+		for uri := range primed {
+			seen[uri] = true
+			tracks = append(tracks, uri)
+		}
+	}
+
 	playlistUrl := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks?limit=100", playlistID)
 	for {
 		req, err := http.NewRequest("GET", playlistUrl, nil)
 		if err != nil {
 			return nil, err
 		}
-		req.Header.Add("Authorization", "Bearer "+s.accessToken)
+		req.Header.Add("Authorization", "Bearer "+s.token())
 
-		body, err := doRequest(req, http.StatusOK)
+		body, err := doRateLimitedRequest(context.Background(), req, http.StatusOK, s.limiter, "")
 		if err != nil {
 			return nil, err
 		}
@@ -137,8 +184,18 @@ func (s *SpotifyPlaylistSync) getTracksInPlaylist(playlistID string) ([]string,
 		if err != nil {
 			return nil, err
 		}
+		var page []string
 		for _, item := range playlistResponse.Items {
-			tracks = append(tracks, item.Track.URI)
+			page = append(page, item.Track.URI)
+			if !seen[item.Track.URI] {
+				seen[item.Track.URI] = true
+				tracks = append(tracks, item.Track.URI)
+			}
+		}
+		if s.store != nil {
+			if err := s.store.RecordTracks(playlistID, page); err != nil {
+				return nil, err
+			}
 		}
 		if playlistResponse.Next == "" {
 			break
@@ -160,9 +217,9 @@ func (s *SpotifyPlaylistSync) getLatestAlbumFromBand(artist string) string {
 		log.Fatal(err)
 	}
 	// set authorization header
-	req.Header.Add("Authorization", "Bearer "+s.accessToken)
+	req.Header.Add("Authorization", "Bearer "+s.token())
 	// make the request
-	body, err := doRequest(req, http.StatusOK)
+	body, err := doRateLimitedRequest(context.Background(), req, http.StatusOK, s.limiter, "")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -180,26 +237,25 @@ func (s *SpotifyPlaylistSync) getLatestAlbumFromBand(artist string) string {
 		return ""
 	}
 	for _, album := range searchResponse.Albums.Items {
-		if areStringsSimilar(album.Artists[0].Name, artist) {
+		if s.matcher.MatchArtist(album.Artists[0].Name, artist) {
 			return album.Name
 		}
 	}
 	return searchResponse.Albums.Items[0].Name
 }
 
-func areStringsSimilar(str1 string, str2 string) bool {
-	return strutil.Similarity(str1, str2, metrics.NewLevenshtein()) > 0.8
-}
-
 func (s *SpotifyPlaylistSync) addLatestAlbumFromBand(playlistID, artist string, alreadySeen map[string]bool) error {
 	// get the latest album from the band
 	latestAlbum := s.getLatestAlbumFromBand(artist)
 	fmt.Printf("latest album from %s is %s\n", artist, latestAlbum)
-	album := Album{Title: fmt.Sprintf("%s - %s", artist, latestAlbum)}
+	album := Album{Title: fmt.Sprintf("%s - %s", artist, latestAlbum), Source: "bands-file"}
 	return s.addAlbumToSpotifyPlaylist(album, playlistID, "", alreadySeen)
 }
 
-func (s *SpotifyPlaylistSync) syncSpotifyPlaylist(playlistID, genre, year string) error {
+// syncSpotifyPlaylist fans a SearchCriteria built from genre/year out to
+// every given AlbumSource, and adds whatever comes back (deduplicated
+// across sources) to the playlist.
+func (s *SpotifyPlaylistSync) syncSpotifyPlaylist(playlistID string, sources []AlbumSource, genre, year string) error {
 	// get current songs in playlist
 	currentTracks, err := s.getTracksInPlaylist(playlistID)
 	if err != nil {
@@ -210,22 +266,21 @@ func (s *SpotifyPlaylistSync) syncSpotifyPlaylist(playlistID, genre, year string
 	for _, track := range currentTracks {
 		tracksAlreadySeen[track] = true
 	}
-	albumsToAdd := make(chan Album)
-	// start a goroutine to process albums
-	go func() {
-		for album := range albumsToAdd {
-			for i := 0; i < 3; i++ {
-				err := s.addAlbumToSpotifyPlaylist(album, playlistID, year, tracksAlreadySeen)
-				if err == nil {
-					break
-				}
-				// retry if there was an error
-				fmt.Println("retrying", album.Title)
-				time.Sleep(30 * time.Second)
+	criteria := SearchCriteria{Style: genre, Year: year}
+	albumsToAdd := fanInAlbumSources(context.Background(), sources, criteria)
+	for album := range albumsToAdd {
+		for i := 0; i < 3; i++ {
+			err := s.addAlbumToSpotifyPlaylist(album, playlistID, year, tracksAlreadySeen)
+			if err == nil {
+				break
 			}
+			// rate-limit backoff already happened inside the request itself;
+			// this is just a short pause before retrying a genuine failure
+			fmt.Println("retrying", album.Title)
+			time.Sleep(2 * time.Second)
 		}
-	}()
-	return s.searchDiscogsForAlbums(albumsToAdd, genre, year)
+	}
+	return nil
 }
 
 func doRequest(req *http.Request, expectedStatusCode int) ([]byte, error) {
@@ -245,6 +300,27 @@ func doRequest(req *http.Request, expectedStatusCode int) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
+// doSimpleRequest is doRequest without the log.Fatal on a transport-level
+// error (DNS, timeout, connection refused): it returns that error instead,
+// so a caller that can tolerate a single failing request - an AlbumSource
+// fanned in among several others, a token refresh about to be retried -
+// doesn't take the whole process down with it.
+func doSimpleRequest(req *http.Request, expectedStatusCode int) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expectedStatusCode {
+		return nil, errors.New(string(body))
+	}
+	return body, nil
+}
+
 func (s *SpotifyPlaylistSync) v(f string, args ...interface{}) {
 	if !s.verbose {
 		return
@@ -253,6 +329,20 @@ func (s *SpotifyPlaylistSync) v(f string, args ...interface{}) {
 }
 
 func (s *SpotifyPlaylistSync) addAlbumToSpotifyPlaylist(album Album, playlistID, year string, currentTracks map[string]bool) error {
+	// split album title into artist and album name
+	albumParts := strings.Split(album.Title, " - ")
+	artistName := albumParts[0]
+	albumName := albumParts[1]
+
+	if s.store != nil {
+		if status, ok, err := s.store.AlbumStatus(album.Source, artistName, albumName); err != nil {
+			return err
+		} else if ok {
+			s.v("skipping %s - %s, already evaluated as %s\n", artistName, albumName, status)
+			return nil
+		}
+	}
+
 	// do a spotify search for this album
 	searchUrl := "https://api.spotify.com/v1/search"
 	data := url.Values{
@@ -263,8 +353,8 @@ func (s *SpotifyPlaylistSync) addAlbumToSpotifyPlaylist(album Album, playlistID,
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Authorization", "Bearer "+s.accessToken)
-	body, err := doRequest(req, http.StatusOK)
+	req.Header.Add("Authorization", "Bearer "+s.token())
+	body, err := doRateLimitedRequest(context.Background(), req, http.StatusOK, s.limiter, "")
 	if err != nil {
 		return err
 	}
@@ -276,14 +366,11 @@ func (s *SpotifyPlaylistSync) addAlbumToSpotifyPlaylist(album Album, playlistID,
 	// return if there are no albums to add
 	if len(searchResponse.Albums.Items) == 0 {
 		fmt.Printf("no albums found for %s\n", album.Title)
+		s.recordAlbumStatus(album, artistName, albumName, store.StatusUnmatched, "")
 		return nil
 	}
 	// get the tracks for the album
 	var albumUrl string
-	// split album title into artist and album name
-	albumParts := strings.Split(album.Title, " - ")
-	artistName := albumParts[0]
-	albumName := albumParts[1]
 
 	for _, searchAlbum := range searchResponse.Albums.Items {
 		// skip if release date doesn't start with the year
@@ -291,22 +378,36 @@ func (s *SpotifyPlaylistSync) addAlbumToSpotifyPlaylist(album Album, playlistID,
 			s.v("skipping %s because release date is %s\n", searchAlbum.Name, searchAlbum.ReleaseDate)
 			continue
 		}
-		if areStringsSimilar(searchAlbum.Artists[0].Name, artistName) && areStringsSimilar(searchAlbum.Name, albumName) {
+		if s.matcher.MatchArtist(searchAlbum.Artists[0].Name, artistName) && s.matcher.MatchAlbum(searchAlbum.Name, albumName) {
 			albumUrl = searchAlbum.Href
 			break
 		}
 	}
 	if albumUrl == "" {
 		fmt.Printf("No match for %s\n", album.Title)
+		s.stats.NoMatch++
+		if s.bandcamp != nil {
+			if bcURL, ok := s.bandcamp.SearchAlbum(artistName, albumName); ok {
+				fmt.Printf("found bandcamp mirror for %s - %s: %s\n", artistName, albumName, bcURL)
+				s.stats.BandcampFound++
+				s.bandcampMatches = append(s.bandcampMatches, BandcampMatch{
+					Artist: artistName,
+					Album:  albumName,
+					URL:    bcURL,
+				})
+			}
+		}
+		s.recordAlbumStatus(album, artistName, albumName, store.StatusUnmatched, "")
 		return nil
 	}
+	s.stats.Added++
 	fmt.Println("adding", artistName, "-", albumName)
 	req, err = http.NewRequest("GET", albumUrl, nil)
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Authorization", "Bearer "+s.accessToken)
-	body, err = doRequest(req, http.StatusOK)
+	req.Header.Add("Authorization", "Bearer "+s.token())
+	body, err = doRateLimitedRequest(context.Background(), req, http.StatusOK, s.limiter, "")
 	if err != nil {
 		return err
 	}
@@ -328,6 +429,13 @@ func (s *SpotifyPlaylistSync) addAlbumToSpotifyPlaylist(album Album, playlistID,
 	}
 	if len(trackUris) == 0 {
 		fmt.Printf("no new tracks to add for %s - %s\n", artistName, albumName)
+		s.recordAlbumStatus(album, artistName, albumName, store.StatusMatched, albumUrl)
+		return nil
+	}
+	if s.dryRun {
+		fmt.Printf("[dry-run] would add %d tracks to playlist for %s - %s\n", len(trackUris), artistName, albumName)
+		// deliberately not recorded: nothing was actually added, so a real
+		// run afterwards must still be free to evaluate and add this album
 		return nil
 	}
 	// encode request as JSON
@@ -342,140 +450,81 @@ func (s *SpotifyPlaylistSync) addAlbumToSpotifyPlaylist(album Album, playlistID,
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Authorization", "Bearer "+s.accessToken)
+	req.Header.Add("Authorization", "Bearer "+s.token())
 	req.Header.Add("Content-Type", "application/json")
-	_, err = doRequest(req, http.StatusCreated)
+	_, err = doRateLimitedRequest(context.Background(), req, http.StatusCreated, s.limiter, "")
 	if err != nil {
 		return err
 	}
 	fmt.Printf("added %d tracks to playlist for %s - %s\n", len(trackUris), artistName, albumName)
+	s.recordAlbumStatus(album, artistName, albumName, store.StatusMatched, albumUrl)
+	if s.store != nil {
+		if err := s.store.RecordAddedTracks(playlistID, s.runID, trackUris); err != nil {
+			fmt.Println("failed to record added tracks:", err)
+		}
+	}
 	return nil
 }
 
-func (s *SpotifyPlaylistSync) searchDiscogsForAlbums(albumsToAdd chan Album, style, year string) error {
-	// search params
-	albumType := "release"
-	format := "Album"
-	// create a url with the search params
-	searchUrl := fmt.Sprintf("%s?type=%s&style=%s&format=%s&year=%s&token=%s&per_page=100", discogsSearchURL, albumType, style, format, year, discogsToken)
-	albumsSeen := map[string]bool{}
-	for {
-		fmt.Println("fetching", searchUrl)
-		// create a new request
-		req, err := http.NewRequest("GET", searchUrl, nil)
-		if err != nil {
-			return err
-		}
-		// set user agent
-		req.Header.Add("User-Agent", "SpotifyPlaylistSync/0.1")
-		// make the request
-		body, err := doRequest(req, http.StatusOK)
-		time.Sleep(1 * time.Second)
-		if err != nil {
-			return err
-		}
-		// unmarshal the response into a struct
-		var discogsResponse DiscogsSearchResponse
-		err = json.Unmarshal(body, &discogsResponse)
-		if err != nil {
-			return err
-		}
-		// iterate over results
-	RESULTS:
-		for _, result := range discogsResponse.Results {
-			//fmt.Printf("found %s\n", result.Title)
-			if len(result.Style) == 1 && !strings.HasSuffix(result.Style[0], style) {
-				s.v("skipping %s - %s because it doesn't match style %s\n", result.Artist[0], result.Title, style)
-				continue
-			}
-			if len(result.Style) > 1 && (!strings.HasSuffix(result.Style[0], style) && !strings.HasSuffix(result.Style[1], style)) {
-				s.v("skipping %s because it doesn't match style %s\n", result.Title, style)
-				continue
-			}
-
-			// skip if any styles are excluded
-			for _, style := range result.Style {
-				for _, exc := range s.excludedStyles {
-					if strings.Contains(style, exc) {
-						s.v("skipping %s because it contains excluded style %s\n", result.Title, exc)
-						break RESULTS
-					}
-				}
-			}
-
-			if result.Community.Have < 10 {
-				s.v("skipping %s because it has less than 10 copies\n", result.Title)
-				continue
-			}
-			// skip if this is a reissue or remaster
-			if isReissueOrRemaster(result.Format) {
-				s.v("skipping %s because it is a reissue or remaster\n", result.Title)
-				continue
-			}
-			if result.MasterURL != "" {
-				masterYear, err := getMasterReleaseYear(result.MasterURL)
-				if err != nil {
-					return err
-				}
-				// skip if master release year is not the same as the search year
-				if masterYear != year {
-					s.v("skipping %s because master release year %s does not match search year %s\n", result.Title, masterYear, year)
-					continue
-				}
-			}
-
-			rx := regexp.MustCompile(`\s\(\d+\)`)
-			title := rx.ReplaceAllString(result.Title, "")
-			if !albumsSeen[title] {
-				// send the album to the channel
-				albumsToAdd <- Album{
-					Title: title,
-				}
-				albumsSeen[title] = true
-			}
-		}
-		// get next url
-		searchUrl = discogsResponse.Pagination.Urls.Next
-		if searchUrl == "" {
-			break
-		}
+// recordAlbumStatus persists album's outcome in the store, if one is
+// configured, so a later incremental run can skip re-evaluating it.
+func (s *SpotifyPlaylistSync) recordAlbumStatus(album Album, artist, title, status, spotifyURI string) {
+	if s.store == nil {
+		return
+	}
+	rec := store.AlbumRecord{
+		Source:     album.Source,
+		Artist:     artist,
+		Title:      title,
+		Status:     status,
+		SpotifyURI: spotifyURI,
+		MasterURL:  album.MasterURL,
+	}
+	if err := s.store.RecordAlbum(rec); err != nil {
+		fmt.Println("failed to record album status:", err)
 	}
-	return nil
 }
 
-func getMasterReleaseYear(masterURL string) (string, error) {
-	// create a new request
-	masterURLWithToken := fmt.Sprintf("%s?token=%s", masterURL, discogsToken)
-	req, err := http.NewRequest("GET", masterURLWithToken, nil)
+// undoRun removes every track a previous run (identified by runID) added
+// to playlistID, both from Spotify and the local store.
+func (s *SpotifyPlaylistSync) undoRun(playlistID, runID string) error {
+	tracks, err := s.store.TracksAddedInRun(playlistID, runID)
 	if err != nil {
-		return "", err
+		return err
 	}
-	// set user agent
-	req.Header.Add("User-Agent", "SpotifyPlaylistSync/0.1")
-	// make the request
-	body, err := doRequest(req, http.StatusOK)
-	time.Sleep(1 * time.Second)
-	if err != nil {
-		return "", err
+	if len(tracks) == 0 {
+		fmt.Printf("no tracks recorded for run %s on playlist %s\n", runID, playlistID)
+		return nil
 	}
-	// unmarshal the response into a struct
-	var masterResponse DiscogsMasterResponse
-	err = json.Unmarshal(body, &masterResponse)
+
+	type trackRef struct {
+		URI string `json:"uri"`
+	}
+	type removeTracksRequest struct {
+		Tracks []trackRef `json:"tracks"`
+	}
+	var body removeTracksRequest
+	for _, uri := range tracks {
+		body.Tracks = append(body.Tracks, trackRef{URI: uri})
+	}
+	payload, err := json.Marshal(body)
 	if err != nil {
-		return "", err
+		return err
 	}
-	// convert year to string
-	year := strconv.Itoa(masterResponse.Year)
-	return year, nil
-}
 
-func isReissueOrRemaster(format []string) bool {
-	for _, f := range format {
-		if f == "Reissue" || f == "Remastered" {
-			return true
-		}
+	playlistUrl := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks", playlistID)
+	req, err := http.NewRequest("DELETE", playlistUrl, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
 	}
-	return false
+	req.Header.Add("Authorization", "Bearer "+s.token())
+	req.Header.Add("Content-Type", "application/json")
+	if _, err := doRateLimitedRequest(context.Background(), req, http.StatusOK, s.limiter, ""); err != nil {
+		return err
+	}
+
+	fmt.Printf("removed %d tracks added by run %s from playlist %s\n", len(tracks), runID, playlistID)
+	return s.store.DeleteRun(playlistID, runID)
 }
 
 func (s *SpotifyPlaylistSync) readBandsFromFile(file string) ([]string, error) {
@@ -492,11 +541,21 @@ func (s *SpotifyPlaylistSync) readBandsFromFile(file string) ([]string, error) {
 	return bands, nil
 }
 
-func (s *SpotifyPlaylistSync) addBandsFromFileToPlaylist(playlistID string, file string) error {
+// addBandsFromFileToPlaylist reads bands from file and adds each one's
+// latest album to playlistID. If similarCount is positive, every band is
+// first expanded via expandSimilarArtists (fanning out up to similarDepth
+// hops through Spotify's related-artists graph, perArtist=similarCount
+// wide at each hop) so related artists' latest albums get added too.
+func (s *SpotifyPlaylistSync) addBandsFromFileToPlaylist(playlistID, file string, similarCount, similarDepth int) error {
 	bands, err := s.readBandsFromFile(file)
 	if err != nil {
 		return err
 	}
+	if similarCount > 0 {
+		expanded := s.expandSimilarArtists(bands, similarCount, similarDepth)
+		fmt.Printf("expanded %d bands to %d with similar-artist search\n", len(bands), len(expanded))
+		bands = expanded
+	}
 	// get current songs in playlist
 	currentTracks, err := s.getTracksInPlaylist(playlistID)
 	if err != nil {
@@ -527,10 +586,59 @@ func Run() {
 	excludeStyles := flag.String("E", "", "Discogs styles to exclude")
 	year := flag.String("y", "", "Year")
 	file := flag.String("f", "", "File of bands to read from")
+	clientCredentials := flag.Bool("client-credentials", false, "Authenticate with client credentials instead of the browser login flow (read-only, for non-interactive/cron runs)")
+	sourcesFlag := flag.String("sources", "discogs", "Comma-separated album sources to search: discogs, musicbrainz, bandcamp, listenbrainz")
+	listenBrainzUser := flag.String("listenbrainz-user", "", "ListenBrainz username, required when listenbrainz is one of -sources")
+	bandcampOut := flag.String("bandcamp-out", "", "Write Bandcamp mirror links for unmatched albums to this file (.json or .md)")
+	matchMetric := flag.String("match-metric", string(MetricJaroWinkler), "String similarity metric for artist/album matching: jaro-winkler, levenshtein, smith-waterman")
+	artistThreshold := flag.Float64("artist-threshold", 0.85, "Similarity threshold (0-1) above which two artist names are considered the same")
+	albumThreshold := flag.Float64("album-threshold", 0.85, "Similarity threshold (0-1) above which two album names are considered the same")
+	dbPath := flag.String("db", "spotify-playlist-sync.db", "Path to the SQLite state database tracking seen albums, playlist tracks and cached master-year lookups")
+	dryRun := flag.Bool("dry-run", false, "Log planned playlist additions without calling Spotify to make them")
+	undoRunID := flag.String("undo", "", "Remove the tracks a previous run (by the run ID it printed) added to -p's playlist, instead of syncing")
+	similarCount := flag.Int("similar", 0, "With -f, also enqueue the latest album from each band's top N related artists (0 disables)")
+	similarDepth := flag.Int("similar-depth", 1, "How many hops of related-artist expansion -similar follows before stopping")
 	flag.Parse()
 
-	s := NewSpotifyPlaylistSync()
+	st, err := store.Open(*dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer st.Close()
+
+	var tokens *TokenSource
+	if *clientCredentials {
+		tokens = NewClientCredentialsTokenSource(clientID, clientSecret)
+	} else {
+		tokenFilePath := os.Getenv("SPOTIFY_TOKEN_FILE")
+		if tokenFilePath == "" {
+			tokenFilePath = ".spotify_token.json"
+		}
+		tokens = NewTokenSource(clientID, clientSecret, tokenFilePath)
+	}
+
+	s := NewSpotifyPlaylistSync(tokens)
+	s.store = st
+
+	if *undoRunID != "" {
+		if *playlistID == "" {
+			log.Fatal("playlist id must be set")
+		}
+		if err := s.undoRun(*playlistID, *undoRunID); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	s.dryRun = *dryRun
+	s.runID = newRunID()
+	fmt.Println("run id:", s.runID)
 	s.excludedStyles = strings.Split(*excludeStyles, ",")
+	s.matcher = NewMatcher(MatchMetric(*matchMetric), *artistThreshold, *albumThreshold)
+	if *bandcampOut != "" {
+		s.bandcamp = NewBandcampClient(s.matcher)
+	}
+	defer s.printSummary(*bandcampOut)
 
 	// if file is set, add albums from bands in file to playlist
 	if *file != "" {
@@ -538,7 +646,7 @@ func Run() {
 		if *playlistID == "" {
 			log.Fatal("playlist id must be set")
 		}
-		if err := s.addBandsFromFileToPlaylist(*playlistID, *file); err != nil {
+		if err := s.addBandsFromFileToPlaylist(*playlistID, *file, *similarCount, *similarDepth); err != nil {
 			log.Fatal(err)
 		}
 		return
@@ -549,9 +657,60 @@ func Run() {
 		log.Fatal("playlist id, style and year must be set")
 	}
 
-	err := s.syncSpotifyPlaylist(*playlistID, *style, *year)
+	sources, err := buildAlbumSources(*sourcesFlag, *listenBrainzUser, s)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = s.syncSpotifyPlaylist(*playlistID, sources, *style, *year)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+// newRunID returns a short, collision-resistant identifier for a sync
+// run, used to tag every track it adds so -undo can find them again.
+func newRunID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// printSummary logs the run totals and, if a Bandcamp output path was
+// configured, writes the verified mirror links to it.
+func (s *SpotifyPlaylistSync) printSummary(bandcampOutPath string) {
+	fmt.Printf("done: %d added, %d no-match, %d bandcamp mirrors found\n", s.stats.Added, s.stats.NoMatch, s.stats.BandcampFound)
+	if bandcampOutPath == "" {
+		return
+	}
+	if err := writeBandcampOutput(bandcampOutPath, s.bandcampMatches); err != nil {
+		fmt.Println("failed to write bandcamp output:", err)
+	}
+}
+
+// buildAlbumSources turns the -sources flag into the AlbumSource
+// implementations to fan a search out to.
+func buildAlbumSources(sourcesFlag, listenBrainzUser string, s *SpotifyPlaylistSync) ([]AlbumSource, error) {
+	var sources []AlbumSource
+	for _, name := range strings.Split(sourcesFlag, ",") {
+		switch strings.TrimSpace(name) {
+		case "discogs":
+			discogs := NewDiscogsSource(discogsToken, s.excludedStyles, s.verbose)
+			discogs.MasterYearCache = s.store
+			sources = append(sources, discogs)
+		case "musicbrainz":
+			sources = append(sources, NewMusicBrainzSource())
+		case "bandcamp":
+			sources = append(sources, NewBandcampSource())
+		case "listenbrainz":
+			if listenBrainzUser == "" {
+				return nil, fmt.Errorf("-listenbrainz-user must be set when listenbrainz is one of -sources")
+			}
+			sources = append(sources, NewListenBrainzSource(listenBrainzUser))
+		case "":
+			// ignore stray commas
+		default:
+			return nil, fmt.Errorf("unknown album source %q", name)
+		}
+	}
+	return sources, nil
+}
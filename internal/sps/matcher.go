@@ -0,0 +1,69 @@
+package sps
+
+import (
+	"github.com/adrg/strutil"
+	"github.com/adrg/strutil/metrics"
+)
+
+// MatchMetric selects the string-similarity algorithm a Matcher uses.
+type MatchMetric string
+
+const (
+	MetricJaroWinkler   MatchMetric = "jaro-winkler"
+	MetricLevenshtein   MatchMetric = "levenshtein"
+	MetricSmithWaterman MatchMetric = "smith-waterman"
+)
+
+// Matcher decides whether two artist or album names refer to the same
+// thing, using normalization plus a configurable similarity metric and
+// threshold. Jaro-Winkler is the default: it tolerates the kind of small
+// punctuation/spelling differences ("AC/DC" vs "ACDC") real catalogs are
+// full of, without being as loose as Levenshtein on short strings like
+// "a-ha" vs "Ash".
+type Matcher struct {
+	Metric          MatchMetric
+	ArtistThreshold float64
+	AlbumThreshold  float64
+}
+
+// NewMatcher builds a Matcher for the given metric and thresholds.
+func NewMatcher(metric MatchMetric, artistThreshold, albumThreshold float64) *Matcher {
+	return &Matcher{
+		Metric:          metric,
+		ArtistThreshold: artistThreshold,
+		AlbumThreshold:  albumThreshold,
+	}
+}
+
+// DefaultMatcher returns a Matcher with the repo's default metric and
+// thresholds.
+func DefaultMatcher() *Matcher {
+	return NewMatcher(MetricJaroWinkler, 0.85, 0.85)
+}
+
+func (m *Matcher) stringMetric() strutil.StringMetric {
+	switch m.Metric {
+	case MetricLevenshtein:
+		return metrics.NewLevenshtein()
+	case MetricSmithWaterman:
+		return metrics.NewSmithWatermanGotoh()
+	default:
+		return metrics.NewJaroWinkler()
+	}
+}
+
+// MatchArtist reports whether a and b are similar enough to be considered
+// the same artist.
+func (m *Matcher) MatchArtist(a, b string) bool {
+	return m.similarity(a, b) >= m.ArtistThreshold
+}
+
+// MatchAlbum reports whether a and b are similar enough to be considered
+// the same album.
+func (m *Matcher) MatchAlbum(a, b string) bool {
+	return m.similarity(a, b) >= m.AlbumThreshold
+}
+
+func (m *Matcher) similarity(a, b string) float64 {
+	return strutil.Similarity(normalizeForMatch(a), normalizeForMatch(b), m.stringMetric())
+}
@@ -0,0 +1,47 @@
+package sps
+
+import "testing"
+
+func TestMatcherMatchArtist(t *testing.T) {
+	m := DefaultMatcher()
+
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"a-ha", "Ash", false},
+		{"AC/DC", "ACDC", true},
+		{"Motörhead", "Motorhead", true},
+		{"Motörhead", "Ash", false},
+	}
+
+	for _, c := range cases {
+		if got := m.MatchArtist(c.a, c.b); got != c.want {
+			t.Errorf("MatchArtist(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestMatcherMatchAlbumIgnoresParentheticalsAndFeaturing(t *testing.T) {
+	m := DefaultMatcher()
+
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"Greatest Hits (Deluxe Edition)", "Greatest Hits", true},
+		{"Greatest Hits [Remastered]", "Greatest Hits", true},
+		{"Greatest Hits - EP", "Greatest Hits", true},
+		{"Thriller (2)", "Thriller", true},
+	}
+
+	for _, c := range cases {
+		if got := m.MatchAlbum(c.a, c.b); got != c.want {
+			t.Errorf("MatchAlbum(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+
+	if !m.MatchArtist("Artist feat. Someone Else", "Artist") {
+		t.Errorf("MatchArtist should ignore a featuring suffix")
+	}
+}
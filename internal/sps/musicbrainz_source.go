@@ -0,0 +1,76 @@
+package sps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const musicBrainzReleaseGroupURL = "https://musicbrainz.org/ws/2/release-group"
+
+type musicBrainzReleaseGroupResponse struct {
+	ReleaseGroups []struct {
+		Title        string `json:"title"`
+		ArtistCredit []struct {
+			Name string `json:"name"`
+		} `json:"artist-credit"`
+		FirstReleaseDate string `json:"first-release-date"`
+	} `json:"release-groups"`
+}
+
+// MusicBrainzSource is an AlbumSource backed by MusicBrainz's release-group
+// search, querying by tag and first-release year. It covers tag/year
+// combinations that Discogs indexes poorly.
+type MusicBrainzSource struct{}
+
+func NewMusicBrainzSource() *MusicBrainzSource {
+	return &MusicBrainzSource{}
+}
+
+func (m *MusicBrainzSource) Search(ctx context.Context, criteria SearchCriteria) <-chan Album {
+	out := make(chan Album)
+	go func() {
+		defer close(out)
+		if err := m.search(ctx, criteria, out); err != nil {
+			fmt.Println("musicbrainz source error:", err)
+		}
+	}()
+	return out
+}
+
+func (m *MusicBrainzSource) search(ctx context.Context, criteria SearchCriteria, out chan<- Album) error {
+	query := fmt.Sprintf("tag:%s AND firstreleasedate:%s", criteria.Style, criteria.Year)
+	searchUrl := fmt.Sprintf("%s?query=%s&fmt=json&limit=100", musicBrainzReleaseGroupURL, url.QueryEscape(query))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", searchUrl, nil)
+	if err != nil {
+		return err
+	}
+	// MusicBrainz asks API clients to identify themselves
+	req.Header.Add("User-Agent", "SpotifyPlaylistSync/0.1 ( https://github.com/mcartmell/spotify-playlist-sync )")
+
+	// doSimpleRequest, not doRequest: a transport error here must stay an
+	// error the fan-in can log and move past, not take the whole sync down.
+	body, err := doSimpleRequest(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var resp musicBrainzReleaseGroupResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	for _, rg := range resp.ReleaseGroups {
+		if len(rg.ArtistCredit) == 0 {
+			continue
+		}
+		album := Album{Title: fmt.Sprintf("%s - %s", rg.ArtistCredit[0].Name, rg.Title), Source: "musicbrainz"}
+		select {
+		case out <- album:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package sps
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	featuringRx     = regexp.MustCompile(`(?i)\s+(feat\.?|ft\.?)\s+.*$`)
+	parentheticalRx = regexp.MustCompile(`\s*\([^)]*\)`)
+	bracketedRx     = regexp.MustCompile(`\s*\[[^\]]*\]`)
+	epSuffixRx      = regexp.MustCompile(`(?i)\s*-\s*ep$`)
+
+	diacriticsTransform = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+)
+
+// normalizeForMatch prepares an artist/album string for fuzzy comparison:
+// it drops "feat."/"ft." credits, strips parentheticals like "(Deluxe
+// Edition)", "[Remastered]" and the Discogs "(2)" disambiguation suffix, a
+// trailing " - EP", then strips diacritics and lowercases what's left.
+func normalizeForMatch(s string) string {
+	s = featuringRx.ReplaceAllString(s, "")
+	s = parentheticalRx.ReplaceAllString(s, "")
+	s = bracketedRx.ReplaceAllString(s, "")
+	s = epSuffixRx.ReplaceAllString(s, "")
+	s = stripDiacritics(s)
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func stripDiacritics(s string) string {
+	result, _, err := transform.String(diacriticsTransform, s)
+	if err != nil {
+		return s
+	}
+	return result
+}
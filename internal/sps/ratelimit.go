@@ -0,0 +1,88 @@
+package sps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// doRateLimitedRequest waits for limiter to admit the request, sends it,
+// and retries on 429 Too Many Requests by honoring the Retry-After header
+// (falling back to 1s if it's missing or malformed) until the response
+// matches expectedStatusCode or a non-rate-limit error occurs. It also
+// backs off briefly when a ratelimit-remaining header reports the budget
+// is nearly exhausted, rather than waiting to actually hit a 429.
+func doRateLimitedRequest(ctx context.Context, req *http.Request, expectedStatusCode int, limiter *rate.Limiter, remainingHeader string) ([]byte, error) {
+	for {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			wait := retryAfter(resp, 1*time.Second)
+			resp.Body.Close()
+			fmt.Printf("rate limited by %s, backing off for %s\n", req.URL.Host, wait)
+			time.Sleep(wait)
+			if err := rewindBody(req); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if remainingHeader != "" {
+			if n, err := strconv.Atoi(resp.Header.Get(remainingHeader)); err == nil && n <= 1 {
+				// nearly out of budget; pause before the next request goes out
+				time.Sleep(1 * time.Second)
+			}
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != expectedStatusCode {
+			return nil, fmt.Errorf("%s", string(body))
+		}
+		return body, nil
+	}
+}
+
+// retryAfter parses a Retry-After header (seconds form) off resp, falling
+// back to def if it's missing or not a plain integer.
+func retryAfter(resp *http.Response, def time.Duration) time.Duration {
+	ra := resp.Header.Get("Retry-After")
+	if ra == "" {
+		return def
+	}
+	secs, err := strconv.Atoi(ra)
+	if err != nil {
+		return def
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// rewindBody resets req.Body via GetBody so the request can be replayed
+// after a 429. Requests built from in-memory buffers (bytes.Buffer,
+// bytes.Reader, strings.Reader) get GetBody set automatically by
+// http.NewRequest; a GET with no body has nothing to rewind.
+func rewindBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
@@ -0,0 +1,129 @@
+package sps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type spotifyArtistSearchResponse struct {
+	Artists struct {
+		Items []spotifyArtist `json:"items"`
+	} `json:"artists"`
+}
+
+type spotifyRelatedArtistsResponse struct {
+	Artists []spotifyArtist `json:"artists"`
+}
+
+type spotifyArtist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// searchArtistID looks up name via Spotify's artist search and returns the
+// ID of the best match, falling back to the top hit if none of the
+// candidates pass the matcher.
+func (s *SpotifyPlaylistSync) searchArtistID(name string) (string, error) {
+	searchUrl := fmt.Sprintf("https://api.spotify.com/v1/search?q=%s&type=artist&limit=10", url.QueryEscape("artist:"+name))
+	req, err := http.NewRequest("GET", searchUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+s.token())
+	body, err := doRateLimitedRequest(context.Background(), req, http.StatusOK, s.limiter, "")
+	if err != nil {
+		return "", err
+	}
+	var resp spotifyArtistSearchResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Artists.Items) == 0 {
+		return "", fmt.Errorf("no artist found for %q", name)
+	}
+	for _, artist := range resp.Artists.Items {
+		if s.matcher.MatchArtist(artist.Name, name) {
+			return artist.ID, nil
+		}
+	}
+	return resp.Artists.Items[0].ID, nil
+}
+
+// getRelatedArtists returns the artists Spotify considers related to id.
+func (s *SpotifyPlaylistSync) getRelatedArtists(id string) ([]spotifyArtist, error) {
+	relatedUrl := fmt.Sprintf("https://api.spotify.com/v1/artists/%s/related-artists", url.PathEscape(id))
+	req, err := http.NewRequest("GET", relatedUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+s.token())
+	body, err := doRateLimitedRequest(context.Background(), req, http.StatusOK, s.limiter, "")
+	if err != nil {
+		return nil, err
+	}
+	var resp spotifyRelatedArtistsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Artists, nil
+}
+
+// similarArtistNode is one artist queued for related-artist expansion.
+type similarArtistNode struct {
+	id    string
+	name  string
+	depth int
+}
+
+// expandSimilarArtists breadth-first-searches Spotify's related-artists
+// graph starting from seeds, following up to maxDepth hops and visiting
+// each artist ID at most once (the depth limit and visited set together
+// keep a single seed from exploding into the whole genre graph). It
+// returns every artist name found, seeds included, in discovery order.
+func (s *SpotifyPlaylistSync) expandSimilarArtists(seeds []string, perArtist, maxDepth int) []string {
+	visited := map[string]bool{}
+	var queue []similarArtistNode
+	var names []string
+
+	for _, name := range seeds {
+		id, err := s.searchArtistID(name)
+		if err != nil {
+			fmt.Println("similar-artists:", err)
+			continue
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		names = append(names, name)
+		queue = append(queue, similarArtistNode{id: id, name: name, depth: 0})
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.depth >= maxDepth {
+			continue
+		}
+		related, err := s.getRelatedArtists(current.id)
+		if err != nil {
+			fmt.Println("similar-artists:", err)
+			continue
+		}
+		for i, artist := range related {
+			if i >= perArtist {
+				break
+			}
+			if visited[artist.ID] {
+				continue
+			}
+			visited[artist.ID] = true
+			names = append(names, artist.Name)
+			queue = append(queue, similarArtistNode{id: artist.ID, name: artist.Name, depth: current.depth + 1})
+		}
+	}
+	return names
+}
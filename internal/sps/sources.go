@@ -0,0 +1,79 @@
+package sps
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// SearchCriteria is the shared query passed to every AlbumSource: a style
+// or tag to search for, and optionally a release year to narrow results
+// to.
+type SearchCriteria struct {
+	Style string
+	Year  string
+}
+
+// AlbumSource discovers candidate albums for a SearchCriteria and streams
+// them back on a channel, closing it once the search is exhausted (or the
+// context is cancelled). Implementations are expected to log their own
+// errors rather than panic, since a single failing source shouldn't take
+// down a sync that's fanning in several of them.
+type AlbumSource interface {
+	Search(ctx context.Context, criteria SearchCriteria) <-chan Album
+}
+
+// fanInAlbumSources runs criteria against every source concurrently and
+// merges their results onto a single channel, deduplicating by normalized
+// "artist - title" so the same album found via two sources (e.g. Discogs
+// and MusicBrainz) only gets added once.
+func fanInAlbumSources(ctx context.Context, sources []AlbumSource, criteria SearchCriteria) <-chan Album {
+	out := make(chan Album)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := map[string]bool{}
+
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source AlbumSource) {
+			defer wg.Done()
+			for album := range source.Search(ctx, criteria) {
+				key := normalizeAlbumKey(album.Title)
+
+				mu.Lock()
+				dup := seen[key]
+				seen[key] = true
+				mu.Unlock()
+
+				if dup {
+					continue
+				}
+				select {
+				case out <- album:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(source)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// normalizeAlbumKey turns an "Artist - Title" string into a form suitable
+// for dedup comparisons across sources, reusing the same normalization
+// the Matcher applies to artist/album names so two sources' minor
+// formatting differences (a diacritic, a trailing Discogs "(2)", extra
+// whitespace) still collapse to the same key instead of slipping past
+// the fan-in dedup as two distinct albums.
+func normalizeAlbumKey(title string) string {
+	parts := strings.SplitN(title, " - ", 2)
+	if len(parts) != 2 {
+		return normalizeForMatch(title)
+	}
+	return normalizeForMatch(parts[0]) + " - " + normalizeForMatch(parts[1])
+}
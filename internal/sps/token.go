@@ -4,90 +4,238 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
-func getSpotifyAccessToken() string {
-	var accessToken string
-	doneCh := make(chan bool)
-	var once sync.Once
-	// start a webserver on localhost:3000 to wait for the access code
-	// from the spotify authorization page
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// get the access code from the url
-		accessCode := r.URL.Query().Get("code")
-		// exchange the access code for an access token
-		once.Do(func() {
-			accessToken = exchangeAccessCodeForAccessToken(accessCode)
-		})
-		// print the access token
-		fmt.Println(accessToken)
-		// close the webserver
-		w.Write([]byte("You can close this window now."))
-		doneCh <- true
-	})
-	// start the webserver
-	go http.ListenAndServe(":3000", nil)
-	// open the spotify authorization page in the default browser
-	fmt.Printf("To continue, open the following link and approve the request:\n  %s?client_id=%s&response_type=code&redirect_uri=http://localhost:3000&scope=playlist-modify-public%%20playlist-modify-private\n", spotifyAuthorizeURL, clientID)
-	// wait for the webserver to close
-	<-doneCh
-	return accessToken
+const (
+	spotifyTokenURL = "https://accounts.spotify.com/api/token"
+	// how far ahead of expiry we refresh, to avoid racing a request against
+	// a token that expires mid-flight
+	tokenExpiryMargin = 60 * time.Second
+)
+
+// TokenSource hands out a valid Spotify access token, transparently
+// refreshing it (or re-exchanging client credentials) once it gets close
+// to expiring. It's safe for concurrent use.
+type TokenSource struct {
+	mu sync.Mutex
+
+	clientID     string
+	clientSecret string
+
+	// clientCredentials selects the client-credentials grant (read-only,
+	// no user context) instead of the authorization-code + refresh flow.
+	clientCredentials bool
+
+	configPath string
+
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// tokenFile is the on-disk shape we persist so restarts don't need the
+// localhost:3000 browser dance again.
+type tokenFile struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// NewTokenSource runs the authorization-code flow (prompting the user to
+// approve in a browser, same as before) unless a refresh token is already
+// persisted at configPath, in which case it resumes from that.
+func NewTokenSource(clientID, clientSecret, configPath string) *TokenSource {
+	t := &TokenSource{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		configPath:   configPath,
+	}
+	if refreshToken, ok := loadRefreshToken(configPath); ok {
+		t.refreshToken = refreshToken
+		if err := t.refresh(); err != nil {
+			log.Fatal(err)
+		}
+		return t
+	}
+	accessCode := getSpotifyAccessCode(clientID)
+	if err := t.exchangeAccessCode(accessCode); err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
+// NewClientCredentialsTokenSource authenticates as the app itself, with no
+// user context. It's for non-interactive sync jobs (e.g. cron) that only
+// need read-only playlist/search access and can't do the browser dance.
+func NewClientCredentialsTokenSource(clientID, clientSecret string) *TokenSource {
+	t := &TokenSource{
+		clientID:          clientID,
+		clientSecret:      clientSecret,
+		clientCredentials: true,
+	}
+	if err := t.refresh(); err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
+// Token returns a currently-valid access token, re-exchanging it first if
+// it's within tokenExpiryMargin of expiring.
+func (t *TokenSource) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Now().Add(tokenExpiryMargin).After(t.expiresAt) {
+		if err := t.refreshLocked(); err != nil {
+			return "", err
+		}
+	}
+	return t.accessToken, nil
+}
+
+func (t *TokenSource) refresh() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.refreshLocked()
 }
 
-func exchangeAccessCodeForAccessToken(accessCode string) string {
-	// create a url with the access code
-	tokenUrl := "https://accounts.spotify.com/api/token"
+// refreshLocked re-exchanges the refresh token (or client credentials) for
+// a fresh access token. Caller must hold t.mu.
+func (t *TokenSource) refreshLocked() error {
+	data := url.Values{}
+	if t.clientCredentials {
+		data.Set("grant_type", "client_credentials")
+	} else {
+		if t.refreshToken == "" {
+			return fmt.Errorf("no refresh token available")
+		}
+		data.Set("grant_type", "refresh_token")
+		data.Set("refresh_token", t.refreshToken)
+	}
+
+	resp, err := t.doTokenRequest(data)
+	if err != nil {
+		return err
+	}
+	t.accessToken = resp.AccessToken
+	t.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	if resp.RefreshToken != "" {
+		t.refreshToken = resp.RefreshToken
+	}
+	if !t.clientCredentials {
+		if err := saveRefreshToken(t.configPath, t.refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exchangeAccessCode trades the authorization-code callback param for the
+// first access + refresh token pair.
+func (t *TokenSource) exchangeAccessCode(accessCode string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	data := url.Values{
 		"grant_type":   {"authorization_code"},
 		"code":         {accessCode},
 		"redirect_uri": {"http://localhost:3000"},
-		"client_id":    {clientID},
 	}
+	resp, err := t.doTokenRequest(data)
+	if err != nil {
+		return err
+	}
+	t.accessToken = resp.AccessToken
+	t.refreshToken = resp.RefreshToken
+	t.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return saveRefreshToken(t.configPath, t.refreshToken)
+}
 
-	// create a new request
-	req, err := http.NewRequest("POST", tokenUrl, strings.NewReader(data.Encode()))
+func (t *TokenSource) doTokenRequest(data url.Values) (*tokenResponse, error) {
+	req, err := http.NewRequest("POST", spotifyTokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	// set authorization header
-	base64ClientAndSecret := base64.StdEncoding.EncodeToString([]byte(clientID + ":" + clientSecret))
+	base64ClientAndSecret := base64.StdEncoding.EncodeToString([]byte(t.clientID + ":" + t.clientSecret))
 	req.Header.Add("Authorization", "Basic "+base64ClientAndSecret)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	// make the request
-	resp, err := http.DefaultClient.Do(req)
+
+	// doSimpleRequest, not doRequest: a transient failure here should come
+	// back as an error Token() can hand to its caller, not kill the process
+	// outright (token() above retries before giving up).
+	body, err := doSimpleRequest(req, http.StatusOK)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	// check status
-	if resp.StatusCode != http.StatusOK {
-		// read the response body
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatal(err)
-		}
-		// print the response body
-		fmt.Println(string(body))
-		log.Fatal("status code is not 200 - access token")
+	var parsed tokenResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
 	}
-	// read the response
-	body, err := io.ReadAll(resp.Body)
+	return &parsed, nil
+}
+
+// loadRefreshToken reads a persisted refresh token from path, if path is
+// set and the file exists.
+func loadRefreshToken(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	body, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return "", false
 	}
-	// unmarshal the response into a struct
-	var tokenResponse struct {
-		AccessToken string `json:"access_token"`
+	var f tokenFile
+	if err := json.Unmarshal(body, &f); err != nil {
+		return "", false
 	}
-	err = json.Unmarshal(body, &tokenResponse)
+	return f.RefreshToken, f.RefreshToken != ""
+}
+
+// saveRefreshToken persists the refresh token to path so restarts don't
+// have to re-prompt. A no-op when path is empty.
+func saveRefreshToken(path, refreshToken string) error {
+	if path == "" {
+		return nil
+	}
+	body, err := json.Marshal(tokenFile{RefreshToken: refreshToken})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	// return the access token
-	return tokenResponse.AccessToken
+	return os.WriteFile(path, body, 0600)
+}
+
+// getSpotifyAccessCode runs the localhost:3000 redirect dance and returns
+// the authorization code from the callback.
+func getSpotifyAccessCode(clientID string) string {
+	var accessCode string
+	doneCh := make(chan bool)
+	var once sync.Once
+	// start a webserver on localhost:3000 to wait for the access code
+	// from the spotify authorization page
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// get the access code from the url
+		once.Do(func() {
+			accessCode = r.URL.Query().Get("code")
+		})
+		w.Write([]byte("You can close this window now."))
+		doneCh <- true
+	})
+	// start the webserver
+	go http.ListenAndServe(":3000", nil)
+	// open the spotify authorization page in the default browser
+	fmt.Printf("To continue, open the following link and approve the request:\n  %s?client_id=%s&response_type=code&redirect_uri=http://localhost:3000&scope=playlist-modify-public%%20playlist-modify-private\n", spotifyAuthorizeURL, clientID)
+	// wait for the webserver to close
+	<-doneCh
+	return accessCode
 }
@@ -0,0 +1,246 @@
+// Package store is a SQLite-backed record of what a sync run has already
+// evaluated. It lets a repeat run skip re-searching albums it's already
+// decided on, caches Discogs master-release years, and keeps enough of a
+// trail (which tracks which run added to which playlist) for a later
+// -undo to find and remove them again.
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Status values recorded for an evaluated album.
+const (
+	StatusMatched   = "matched"
+	StatusUnmatched = "unmatched"
+)
+
+// AlbumRecord is one album a sync run considered, kept so a later
+// incremental run doesn't have to re-evaluate it.
+type AlbumRecord struct {
+	Source     string
+	Artist     string
+	Title      string
+	Status     string
+	SpotifyURI string
+	MasterURL  string
+}
+
+// Store wraps a SQLite database of seen albums, playlist tracks and
+// cached Discogs master-release years. It's safe for concurrent use; the
+// underlying *sql.DB pools its own connections.
+type Store struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS seen_albums (
+	source      TEXT NOT NULL,
+	artist      TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	spotify_uri TEXT NOT NULL DEFAULT '',
+	master_url  TEXT NOT NULL DEFAULT '',
+	checked_at  DATETIME NOT NULL,
+	PRIMARY KEY (source, artist, title)
+);
+
+CREATE TABLE IF NOT EXISTS master_years (
+	master_url TEXT PRIMARY KEY,
+	year       TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS playlist_tracks (
+	playlist_id TEXT NOT NULL,
+	track_uri   TEXT NOT NULL,
+	run_id      TEXT NOT NULL DEFAULT '',
+	added_at    DATETIME NOT NULL,
+	PRIMARY KEY (playlist_id, track_uri)
+);
+`
+
+// Open opens (creating if necessary) the SQLite database at path and
+// applies its schema.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows only one writer at a time; callers like the Discogs
+	// worker pool (discogs_source.go) hit CacheMasterYear concurrently
+	// from several goroutines, and a busy_timeout pragma alone isn't
+	// enough to stop that from surfacing as SQLITE_BUSY under load with
+	// Go's connection pool opening multiple connections. Limiting to a
+	// single connection serializes access through the one DB handle
+	// instead.
+	db.SetMaxOpenConns(1)
+	s := &Store{db: db}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RecordAlbum upserts rec, recording that an album was evaluated (and
+// what came of it) so a later incremental run can skip it.
+func (s *Store) RecordAlbum(rec AlbumRecord) error {
+	_, err := s.db.Exec(
+		`INSERT INTO seen_albums (source, artist, title, status, spotify_uri, master_url, checked_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (source, artist, title) DO UPDATE SET
+			status = excluded.status,
+			spotify_uri = excluded.spotify_uri,
+			master_url = excluded.master_url,
+			checked_at = excluded.checked_at`,
+		rec.Source, rec.Artist, rec.Title, rec.Status, rec.SpotifyURI, rec.MasterURL, time.Now().UTC(),
+	)
+	return err
+}
+
+// AlbumStatus reports whether source/artist/title has already been
+// evaluated, and if so, what its recorded status was.
+func (s *Store) AlbumStatus(source, artist, title string) (status string, ok bool, err error) {
+	err = s.db.QueryRow(
+		`SELECT status FROM seen_albums WHERE source = ? AND artist = ? AND title = ?`,
+		source, artist, title,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return status, true, nil
+}
+
+// MasterYear returns a previously cached Discogs master-release year for
+// masterURL, if one has been looked up before.
+func (s *Store) MasterYear(masterURL string) (year string, ok bool, err error) {
+	err = s.db.QueryRow(`SELECT year FROM master_years WHERE master_url = ?`, masterURL).Scan(&year)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return year, true, nil
+}
+
+// CacheMasterYear records the resolved release year for a Discogs master
+// URL so future lookups skip the network.
+func (s *Store) CacheMasterYear(masterURL, year string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO master_years (master_url, year) VALUES (?, ?)
+		 ON CONFLICT (master_url) DO UPDATE SET year = excluded.year`,
+		masterURL, year,
+	)
+	return err
+}
+
+// SeenTracks returns every track URI previously recorded for playlistID,
+// whether primed from a playlist fetch or added by a prior run. It seeds
+// a resumed run's already-seen set after a crash mid-pagination.
+func (s *Store) SeenTracks(playlistID string) (map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT track_uri FROM playlist_tracks WHERE playlist_id = ?`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[string]bool{}
+	for rows.Next() {
+		var uri string
+		if err := rows.Scan(&uri); err != nil {
+			return nil, err
+		}
+		seen[uri] = true
+	}
+	return seen, rows.Err()
+}
+
+// RecordTracks primes the store with tracks known to already be in
+// playlistID (e.g. fetched from Spotify), without attributing them to any
+// run.
+func (s *Store) RecordTracks(playlistID string, trackURIs []string) error {
+	return s.recordTracks(playlistID, "", trackURIs)
+}
+
+// RecordAddedTracks records trackURIs as having been added to playlistID
+// by runID, so a later `-undo runID` can find and remove them again.
+func (s *Store) RecordAddedTracks(playlistID, runID string, trackURIs []string) error {
+	return s.recordTracks(playlistID, runID, trackURIs)
+}
+
+// recordTracks upserts trackURIs for playlistID. A priming call (runID
+// "") never clobbers a run_id a previous call already set, so re-fetching
+// a playlist's current tracks can't erase which run added them.
+func (s *Store) recordTracks(playlistID, runID string, trackURIs []string) error {
+	if len(trackURIs) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(
+		`INSERT INTO playlist_tracks (playlist_id, track_uri, run_id, added_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (playlist_id, track_uri) DO UPDATE SET
+			run_id = CASE WHEN excluded.run_id != '' THEN excluded.run_id ELSE playlist_tracks.run_id END,
+			added_at = excluded.added_at`,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, uri := range trackURIs {
+		if _, err := stmt.Exec(playlistID, uri, runID, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// TracksAddedInRun returns the track URIs recorded as added to
+// playlistID by runID.
+func (s *Store) TracksAddedInRun(playlistID, runID string) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT track_uri FROM playlist_tracks WHERE playlist_id = ? AND run_id = ?`,
+		playlistID, runID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []string
+	for rows.Next() {
+		var uri string
+		if err := rows.Scan(&uri); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, uri)
+	}
+	return tracks, rows.Err()
+}
+
+// DeleteRun forgets that runID added anything to playlistID, once its
+// tracks have been removed from Spotify by -undo.
+func (s *Store) DeleteRun(playlistID, runID string) error {
+	_, err := s.db.Exec(
+		`DELETE FROM playlist_tracks WHERE playlist_id = ? AND run_id = ?`,
+		playlistID, runID,
+	)
+	return err
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestAlbumStatusRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, ok, err := s.AlbumStatus("discogs", "Boris", "Flood"); err != nil {
+		t.Fatalf("AlbumStatus: %v", err)
+	} else if ok {
+		t.Fatalf("AlbumStatus reported seen before any record was written")
+	}
+
+	rec := AlbumRecord{Source: "discogs", Artist: "Boris", Title: "Flood", Status: StatusMatched, SpotifyURI: "spotify:album:1", MasterURL: "https://api.discogs.com/masters/1"}
+	if err := s.RecordAlbum(rec); err != nil {
+		t.Fatalf("RecordAlbum: %v", err)
+	}
+
+	status, ok, err := s.AlbumStatus("discogs", "Boris", "Flood")
+	if err != nil {
+		t.Fatalf("AlbumStatus: %v", err)
+	}
+	if !ok || status != StatusMatched {
+		t.Fatalf("AlbumStatus = %q, %v, want %q, true", status, ok, StatusMatched)
+	}
+
+	// a later evaluation with a different outcome overwrites the record
+	rec.Status = StatusUnmatched
+	if err := s.RecordAlbum(rec); err != nil {
+		t.Fatalf("RecordAlbum (update): %v", err)
+	}
+	if status, _, err := s.AlbumStatus("discogs", "Boris", "Flood"); err != nil {
+		t.Fatalf("AlbumStatus: %v", err)
+	} else if status != StatusUnmatched {
+		t.Fatalf("AlbumStatus after update = %q, want %q", status, StatusUnmatched)
+	}
+}
+
+func TestMasterYearCache(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, ok, err := s.MasterYear("https://api.discogs.com/masters/42"); err != nil {
+		t.Fatalf("MasterYear: %v", err)
+	} else if ok {
+		t.Fatalf("MasterYear reported cached before any write")
+	}
+
+	if err := s.CacheMasterYear("https://api.discogs.com/masters/42", "1998"); err != nil {
+		t.Fatalf("CacheMasterYear: %v", err)
+	}
+
+	year, ok, err := s.MasterYear("https://api.discogs.com/masters/42")
+	if err != nil {
+		t.Fatalf("MasterYear: %v", err)
+	}
+	if !ok || year != "1998" {
+		t.Fatalf("MasterYear = %q, %v, want %q, true", year, ok, "1998")
+	}
+}
+
+func TestPrimingDoesNotClobberRunID(t *testing.T) {
+	s := openTestStore(t)
+	playlistID := "playlist1"
+
+	if err := s.RecordAddedTracks(playlistID, "run1", []string{"spotify:track:a"}); err != nil {
+		t.Fatalf("RecordAddedTracks: %v", err)
+	}
+
+	// a later run re-fetching the playlist's current tracks (priming,
+	// with no run ID of its own) must not erase which run added them
+	if err := s.RecordTracks(playlistID, []string{"spotify:track:a", "spotify:track:b"}); err != nil {
+		t.Fatalf("RecordTracks: %v", err)
+	}
+
+	added, err := s.TracksAddedInRun(playlistID, "run1")
+	if err != nil {
+		t.Fatalf("TracksAddedInRun: %v", err)
+	}
+	if len(added) != 1 || added[0] != "spotify:track:a" {
+		t.Fatalf("TracksAddedInRun(run1) = %v, want [spotify:track:a]", added)
+	}
+
+	seen, err := s.SeenTracks(playlistID)
+	if err != nil {
+		t.Fatalf("SeenTracks: %v", err)
+	}
+	if !seen["spotify:track:a"] || !seen["spotify:track:b"] {
+		t.Fatalf("SeenTracks = %v, want both tracks present", seen)
+	}
+}
+
+// TestConcurrentCacheMasterYearWrites mirrors how the Discogs worker pool
+// (discogs_source.go's worker) hits CacheMasterYear from several
+// goroutines at once: it must not surface as SQLITE_BUSY.
+func TestConcurrentCacheMasterYearWrites(t *testing.T) {
+	s := openTestStore(t)
+
+	const workers = 20
+	const perWorker = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				masterURL := fmt.Sprintf("https://api.discogs.com/masters/%d", w)
+				if err := s.CacheMasterYear(masterURL, strconv.Itoa(1990+i)); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("CacheMasterYear under concurrent load: %v", err)
+	}
+}
+
+func TestDeleteRun(t *testing.T) {
+	s := openTestStore(t)
+	playlistID := "playlist1"
+
+	if err := s.RecordAddedTracks(playlistID, "run1", []string{"spotify:track:a", "spotify:track:b"}); err != nil {
+		t.Fatalf("RecordAddedTracks: %v", err)
+	}
+	if err := s.DeleteRun(playlistID, "run1"); err != nil {
+		t.Fatalf("DeleteRun: %v", err)
+	}
+
+	added, err := s.TracksAddedInRun(playlistID, "run1")
+	if err != nil {
+		t.Fatalf("TracksAddedInRun: %v", err)
+	}
+	if len(added) != 0 {
+		t.Fatalf("TracksAddedInRun after DeleteRun = %v, want none", added)
+	}
+}